@@ -1,6 +1,7 @@
 package concurrent
 
 import (
+	"context"
 	"math"
 	"strconv"
 	"sync"
@@ -8,53 +9,111 @@ import (
 	"time"
 )
 
-// TimeoutCond is a sync.Cond  improve for support wait timeout.
+// TimeoutCond is a sync.Cond improve for support wait timeout.
+//
+// Each call to Wait/WaitWithTimeout/WaitWithContext registers a private
+// ticket (a *waiter) in the current generation's queue and parks on its
+// own channel; Signal/Broadcast/Interrupt wake a waiter by closing that
+// waiter's channel rather than sending on a shared one, so a wakeup can
+// never be silently dropped because the receiver hadn't reached its
+// select yet, and it can never be delivered to a channel that's already
+// closed.
+//
+// The queue and generation counter are guarded by an internal mutex of
+// their own, not by L: L belongs to the caller and protects whatever
+// condition Wait's caller is checking, the same as sync.Cond.L. Signal
+// in particular has to stay callable under the standard
+// "L.Lock(); mutate state; Signal(); L.Unlock()" pattern, so it must
+// never try to reacquire L itself. HasWaiters goes a step further and
+// needs no lock at all: it only reads an atomic counter.
+//
+// This type is not lock-free: apart from HasWaiters, every operation
+// (addWaiter/removeWaiter, Signal, Broadcast, Interrupt) takes the
+// private mutex above, and BenchmarkSignalUncontended /
+// BenchmarkWaitSignalPingPong measure that lock-based implementation.
+//
+// Previously (before this type grew an internal mutex), only Interrupt
+// took a lock at all -- Signal was a non-blocking send on a shared
+// channel, which is what let a wakeup go missing if nobody was
+// receiving yet.
+//
+// Generations are what prevent "signal stealing": a Signal issued while
+// the current generation's queue is empty advances to a new generation
+// instead of being stored up, so a goroutine that calls Wait afterwards
+// starts in that new generation and can't be handed a signal that was
+// meant for nobody.
 type TimeoutCond struct {
-	L          sync.Locker
-	signal     chan int
-	hasWaiters uint64
-}
+	L sync.Locker
 
-// NewTimeoutCond return a new TimeoutCond
-func NewTimeoutCond(l sync.Locker) *TimeoutCond {
-	cond := TimeoutCond{L: l, signal: make(chan int, 0)}
-	return &cond
+	hasWaiters uint64 // atomic; lock-free fast path for HasWaiters
+
+	mu      sync.Mutex // guards currGen and queue; independent of L
+	currGen uint64
+	queue   map[uint64][]*waiter
 }
 
-// WaitWithTimeout wait for signal return remain wait time, and is interrupted
-func (cond *TimeoutCond) WaitWithTimeout(timeout time.Duration) (time.Duration, bool) {
-	cond.addWaiter()
-	ch := cond.signal
-	//wait should unlock mutex,  if not will cause deadlock
-	cond.L.Unlock()
-	defer cond.removeWaiter()
-	defer cond.L.Lock()
+// waiter is one goroutine's private wakeup ticket. ch is closed exactly
+// once, by whichever of Signal/Broadcast/Interrupt hands this waiter its
+// wakeup; interrupted records whether that was Interrupt.
+type waiter struct {
+	ch          chan struct{}
+	interrupted bool
+}
 
-	begin := time.Now().UnixNano()
-	select {
-	case _, ok := <-ch:
-		end := time.Now().UnixNano()
-		remainTimeout := timeout - time.Duration(end-begin)
-		return remainTimeout, !ok
-	case <-time.After(timeout):
-		return 0, false
+// NewTimeoutCond return a new TimeoutCond
+func NewTimeoutCond(l sync.Locker) *TimeoutCond {
+	return &TimeoutCond{
+		L:     l,
+		queue: make(map[uint64][]*waiter),
 	}
 }
 
-func (cond *TimeoutCond) addWaiter() {
+// addWaiter registers the calling goroutine as a waiter of the current
+// generation and returns that generation along with its ticket.
+func (cond *TimeoutCond) addWaiter() (uint64, *waiter) {
 	v := atomic.AddUint64(&cond.hasWaiters, 1)
 	if v == 0 {
 		panic("too many waiters; max is " + strconv.FormatUint(math.MaxUint64, 10))
 	}
+
+	cond.mu.Lock()
+	defer cond.mu.Unlock()
+	gen := cond.currGen
+	w := &waiter{ch: make(chan struct{})}
+	cond.queue[gen] = append(cond.queue[gen], w)
+	return gen, w
 }
 
-func (cond *TimeoutCond) removeWaiter() {
+// dequeueIfPresent removes w from gen's queue if it's still there and
+// reports whether it found it. It must be called with mu held.
+func (cond *TimeoutCond) dequeueIfPresent(gen uint64, w *waiter) bool {
+	q := cond.queue[gen]
+	for i, qw := range q {
+		if qw == w {
+			cond.queue[gen] = append(q[:i:i], q[i+1:]...)
+			if len(cond.queue[gen]) == 0 {
+				delete(cond.queue, gen)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// removeWaiter undoes addWaiter: it drops w from gen's queue if Signal
+// hasn't already popped it, and always accounts for hasWaiters. It
+// reports whether w was still queued (i.e. nobody had woken it yet).
+func (cond *TimeoutCond) removeWaiter(gen uint64, w *waiter) bool {
+	cond.mu.Lock()
+	stillQueued := cond.dequeueIfPresent(gen, w)
+	cond.mu.Unlock()
+
 	// Decrement. See notes here: https://godoc.org/sync/atomic#AddUint64
 	v := atomic.AddUint64(&cond.hasWaiters, ^uint64(0))
-
 	if v == math.MaxUint64 {
 		panic("removeWaiter called more than once after addWaiter")
 	}
+	return stillQueued
 }
 
 // HasWaiters queries whether any goroutine are waiting on this condition
@@ -64,28 +123,136 @@ func (cond *TimeoutCond) HasWaiters() bool {
 
 // Wait for signal return waiting is interrupted
 func (cond *TimeoutCond) Wait() bool {
-	cond.addWaiter()
-	//copy signal in lock, avoid data race with Interrupt
-	ch := cond.signal
+	gen, w := cond.addWaiter()
+	//wait should unlock mutex,  if not will cause deadlock
 	cond.L.Unlock()
-	defer cond.removeWaiter()
-	defer cond.L.Lock()
-	_, ok := <-ch
-	return !ok
+
+	<-w.ch
+
+	cond.L.Lock()
+	cond.removeWaiter(gen, w)
+	return w.interrupted
 }
 
-// Signal wakes one goroutine waiting on c, if there is any.
-func (cond *TimeoutCond) Signal() {
+// WaitWithTimeout wait for signal return remain wait time, and is interrupted
+func (cond *TimeoutCond) WaitWithTimeout(timeout time.Duration) (time.Duration, bool) {
+	gen, w := cond.addWaiter()
+	cond.L.Unlock()
+
+	begin := time.Now().UnixNano()
+	timedOut := false
 	select {
-	case cond.signal <- 1:
-	default:
+	case <-w.ch:
+	case <-time.After(timeout):
+		timedOut = true
+	}
+
+	cond.L.Lock()
+	stillQueued := cond.removeWaiter(gen, w)
+
+	if timedOut && stillQueued {
+		// Genuine timeout: we removed ourselves before any
+		// Signal/Broadcast/Interrupt could reach us.
+		return 0, false
 	}
+	if timedOut {
+		// Raced with a wakeup delivered right as the timer fired: w was
+		// already dequeued for us, so take it instead of reporting a
+		// bogus timeout. ch is closed, so this never blocks.
+		<-w.ch
+	}
+	end := time.Now().UnixNano()
+	return timeout - time.Duration(end-begin), w.interrupted
 }
 
-// Interrupt goroutine wait on this TimeoutCond
-func (cond *TimeoutCond) Interrupt() {
+// WaitWithContext waits for a signal, Broadcast, Interrupt, or ctx to be
+// done, whichever happens first. Like Wait, it releases cond.L while
+// parked and reacquires it before returning. It reports whether the
+// wait ended because ctx was done, as opposed to a signal/interrupt.
+//
+// This lets a caller cancel a single wait (e.g. a pool giving up on one
+// borrower) via ctx instead of reaching for the all-or-nothing
+// Interrupt, which would also wake every other waiter.
+//
+// BLOCKED: plumbing ctx through ObjectPool.BorrowObject/ReturnObject/
+// Close and PooledObjectFactory.MakeObject/ValidateObject, as requested,
+// is not done here and can't be from this package — this repo snapshot
+// has no pool package for WaitWithContext to be wired into.
+func (cond *TimeoutCond) WaitWithContext(ctx context.Context) bool {
+	gen, w := cond.addWaiter()
+	cond.L.Unlock()
+
+	cancelled := false
+	select {
+	case <-w.ch:
+	case <-ctx.Done():
+		cancelled = true
+	}
+
 	cond.L.Lock()
-	defer cond.L.Unlock()
-	close(cond.signal)
-	cond.signal = make(chan int, 0)
+	stillQueued := cond.removeWaiter(gen, w)
+
+	if cancelled && stillQueued {
+		return true
+	}
+	if cancelled {
+		// Raced with a wakeup; ch is already closed so this never blocks.
+		<-w.ch
+	}
+	return false
+}
+
+// Signal wakes one goroutine waiting on c, if there is any. A Signal
+// issued while the current generation's queue is empty is not stored up
+// for the next waiter: it advances the generation instead, so that
+// waiter can only be woken by a later Signal/Broadcast/Interrupt.
+//
+// Signal does not take L, so it is safe to call in the usual
+// "L.Lock(); mutate state; Signal(); L.Unlock()" pattern without
+// deadlocking.
+func (cond *TimeoutCond) Signal() {
+	cond.mu.Lock()
+	defer cond.mu.Unlock()
+
+	gen := cond.currGen
+	q := cond.queue[gen]
+	if len(q) == 0 {
+		cond.currGen++
+		return
+	}
+	w := q[0]
+	cond.queue[gen] = q[1:]
+	if len(cond.queue[gen]) == 0 {
+		delete(cond.queue, gen)
+	}
+	close(w.ch)
+}
+
+// Broadcast wakes all goroutines currently waiting on cond, without
+// marking them as interrupted, and advances the generation. Like
+// Interrupt, a Broadcast can be followed by further Wait calls that
+// behave normally. Like Signal, Broadcast does not take L.
+func (cond *TimeoutCond) Broadcast() {
+	cond.wakeCurrentGen(false)
+}
+
+// Interrupt goroutine wait on this TimeoutCond. Like Signal, Interrupt
+// does not take L.
+func (cond *TimeoutCond) Interrupt() {
+	cond.wakeCurrentGen(true)
+}
+
+// wakeCurrentGen wakes every waiter of the current generation by closing
+// its ticket and advances to a fresh generation.
+func (cond *TimeoutCond) wakeCurrentGen(interrupted bool) {
+	cond.mu.Lock()
+	defer cond.mu.Unlock()
+
+	gen := cond.currGen
+	for _, w := range cond.queue[gen] {
+		w.interrupted = interrupted
+		close(w.ch)
+	}
+	delete(cond.queue, gen)
+	cond.currGen++
 }
@@ -0,0 +1,116 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTimeoutCondSignalStealing mirrors the upstream sync.Cond
+// TestCondSignalStealing pattern: a Signal issued while nobody is
+// waiting must not be delivered to a goroutine that calls Wait only
+// afterwards.
+func TestTimeoutCondSignalStealing(t *testing.T) {
+	var mu sync.Mutex
+	cond := NewTimeoutCond(&mu)
+
+	cond.Signal() // nobody is waiting; this must be dropped, not stored up
+
+	const timeout = 200 * time.Millisecond
+	start := time.Now()
+	mu.Lock()
+	_, interrupted := cond.WaitWithTimeout(timeout)
+	mu.Unlock()
+	elapsed := time.Since(start)
+
+	if interrupted {
+		t.Fatal("WaitWithTimeout reported interrupted on a plain timeout")
+	}
+	// If the stale Signal above had been delivered to this waiter, it
+	// would have returned almost immediately instead of waiting out the
+	// full timeout.
+	if elapsed < timeout/2 {
+		t.Fatalf("waiter woke after %s, well before its %s timeout; it stole a signal sent before it started waiting", elapsed, timeout)
+	}
+}
+
+// waitForQueuedWaiters blocks until at least n goroutines are registered
+// in cond's current generation. Polling HasWaiters() (or sleeping a
+// fixed duration) isn't precise enough for tests that need *all* of a
+// known number of waiters queued before firing a Broadcast/Interrupt/
+// Signal, so this reads cond's internal queue directly under cond's own
+// internal mutex (queue/currGen are guarded by cond.mu, not L).
+func waitForQueuedWaiters(cond *TimeoutCond, n int) {
+	for {
+		cond.mu.Lock()
+		count := len(cond.queue[cond.currGen])
+		cond.mu.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestTimeoutCondSignalWhileLHeld guards against Signal/Broadcast taking
+// L internally: under the standard sync.Cond calling convention —
+// L.Lock(); mutate shared state; Signal(); L.Unlock() — that would hang
+// forever on the reentrant Lock(). Signal and Broadcast must be callable
+// with L already held by the calling goroutine.
+func TestTimeoutCondSignalWhileLHeld(t *testing.T) {
+	var mu sync.Mutex
+	cond := NewTimeoutCond(&mu)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mu.Lock()
+		cond.Signal()
+		cond.Broadcast()
+		mu.Unlock()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Signal/Broadcast deadlocked while the caller held L")
+	}
+}
+
+// TestTimeoutCondSignalDeliveredToRegisteredWaiter guards against the
+// opposite bug: a Signal must reach a waiter that has already registered
+// (incremented the waiter count) even if that waiter hasn't reached its
+// blocking receive yet, i.e. the wakeup must not be silently dropped.
+func TestTimeoutCondSignalDeliveredToRegisteredWaiter(t *testing.T) {
+	var mu sync.Mutex
+	cond := NewTimeoutCond(&mu)
+
+	const waiters = 8
+	var wg sync.WaitGroup
+	wg.Add(waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			cond.Wait()
+		}()
+	}
+
+	waitForQueuedWaiters(cond, waiters)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < waiters; i++ {
+			cond.Signal()
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("not all waiters were woken; a signal was lost")
+	}
+}